@@ -0,0 +1,267 @@
+package main
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// AuthToken is one entry of the proxy's bearer-token auth list. A request
+// must present Token (or hash to TokenHash, for operators who don't want
+// the raw token in the config file) via "Authorization: Bearer <token>" or
+// "X-Proxy-Token: <token>" to reach a model matching AllowedModel, and its
+// thinking-token usage is metered against DailyThinkingBudget and
+// MonthlyThinkingBudget. MaxBudgetTokens, if set, overrides the per-request
+// thinking budget parsed in processThinking with a hard ceiling.
+type AuthToken struct {
+	Name         string `json:"name"`
+	Token        string `json:"token"`
+	TokenHash    string `json:"tokenHash"`
+	AllowedModel string `json:"allowedModel"`
+
+	DailyThinkingBudget   int `json:"dailyThinkingBudget"`
+	MonthlyThinkingBudget int `json:"monthlyThinkingBudget"`
+	MaxBudgetTokens       int `json:"maxBudgetTokens"`
+}
+
+// key identifies this token entry in the usage store without requiring the
+// raw token to be kept around once it's matched.
+func (t AuthToken) key() string {
+	switch {
+	case t.Name != "":
+		return t.Name
+	case t.Token != "":
+		return t.Token
+	default:
+		return t.TokenHash
+	}
+}
+
+// label identifies this token entry for display in observability output
+// (currently /admin/tokens) without ever echoing back its raw credential.
+// Unlike key(), which falls back to the plaintext Token to stay unique
+// across unnamed entries, label falls back to a short hash prefix instead.
+func (t AuthToken) label() string {
+	switch {
+	case t.Name != "":
+		return t.Name
+	case t.TokenHash != "":
+		return "sha256:" + truncateHash(strings.ToLower(t.TokenHash))
+	case t.Token != "":
+		sum := sha256.Sum256([]byte(t.Token))
+		return "sha256:" + truncateHash(hex.EncodeToString(sum[:]))
+	default:
+		return "unnamed"
+	}
+}
+
+const tokenLabelHashChars = 12
+
+func truncateHash(hash string) string {
+	if len(hash) > tokenLabelHashChars {
+		return hash[:tokenLabelHashChars]
+	}
+	return hash
+}
+
+func (t AuthToken) matches(candidate string) bool {
+	if candidate == "" {
+		return false
+	}
+	if t.Token != "" {
+		return subtle.ConstantTimeCompare([]byte(t.Token), []byte(candidate)) == 1
+	}
+	if t.TokenHash != "" {
+		sum := sha256.Sum256([]byte(candidate))
+		return subtle.ConstantTimeCompare([]byte(hex.EncodeToString(sum[:])), []byte(strings.ToLower(t.TokenHash))) == 1
+	}
+	return false
+}
+
+func (t AuthToken) allowsModel(model string) bool {
+	if t.AllowedModel == "" {
+		return true
+	}
+	return matchesModelPattern(t.AllowedModel, model)
+}
+
+// extractBearerToken pulls the caller's credential from either the standard
+// Authorization header or the Cf-Access-Token-style X-Proxy-Token header.
+func extractBearerToken(r *http.Request) string {
+	if auth := r.Header.Get("Authorization"); strings.HasPrefix(auth, "Bearer ") {
+		return strings.TrimPrefix(auth, "Bearer ")
+	}
+	return r.Header.Get("X-Proxy-Token")
+}
+
+const unlimitedBudget = int(^uint(0) >> 1)
+
+// tokenUsage tracks one token's thinking-token consumption, rolling the
+// daily and monthly counters over at their respective boundaries.
+type tokenUsage struct {
+	mu          sync.Mutex
+	day         string
+	dailyUsed   int
+	month       string
+	monthlyUsed int
+}
+
+func (u *tokenUsage) rollover(now time.Time) {
+	day := now.Format("2006-01-02")
+	if u.day != day {
+		u.day = day
+		u.dailyUsed = 0
+	}
+	month := now.Format("2006-01")
+	if u.month != month {
+		u.month = month
+		u.monthlyUsed = 0
+	}
+}
+
+func (u *tokenUsage) remaining(dailyBudget, monthlyBudget int, now time.Time) (int, int) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.rollover(now)
+
+	daily := unlimitedBudget
+	if dailyBudget > 0 {
+		daily = dailyBudget - u.dailyUsed
+	}
+	monthly := unlimitedBudget
+	if monthlyBudget > 0 {
+		monthly = monthlyBudget - u.monthlyUsed
+	}
+	return daily, monthly
+}
+
+func (u *tokenUsage) consume(thinkingTokens int, now time.Time) {
+	if thinkingTokens <= 0 {
+		return
+	}
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.rollover(now)
+	u.dailyUsed += thinkingTokens
+	u.monthlyUsed += thinkingTokens
+}
+
+// AuthStore holds the live thinking-token usage counters for every
+// configured AuthToken, keyed by AuthToken.key().
+type AuthStore struct {
+	mu    sync.Mutex
+	usage map[string]*tokenUsage
+}
+
+func newAuthStore() *AuthStore {
+	return &AuthStore{usage: make(map[string]*tokenUsage)}
+}
+
+// authStore is the process-wide token usage registry, mirroring the
+// metrics singleton: one proxy process, one set of live counters.
+var authStore = newAuthStore()
+
+func (s *AuthStore) usageFor(key string) *tokenUsage {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	u, ok := s.usage[key]
+	if !ok {
+		u = &tokenUsage{}
+		s.usage[key] = u
+	}
+	return u
+}
+
+// authorize finds the AuthToken entry matching the bearer credential on r
+// and checks it against model and remaining quota. When cfg.AuthTokens is
+// empty, auth is disabled entirely and every request passes, preserving
+// the proxy's original trust-everything default.
+func (s *AuthStore) authorize(cfg ProxyConfig, r *http.Request, model string) (AuthToken, bool, string) {
+	if len(cfg.AuthTokens) == 0 {
+		return AuthToken{}, true, ""
+	}
+
+	candidate := extractBearerToken(r)
+	if candidate == "" {
+		return AuthToken{}, false, "missing bearer token"
+	}
+
+	for _, entry := range cfg.AuthTokens {
+		if !entry.matches(candidate) {
+			continue
+		}
+		if !entry.allowsModel(model) {
+			return entry, false, "model not permitted for this token"
+		}
+
+		daily, monthly := s.usageFor(entry.key()).remaining(entry.DailyThinkingBudget, entry.MonthlyThinkingBudget, time.Now())
+		if daily <= 0 || monthly <= 0 {
+			return entry, false, "thinking token quota exhausted"
+		}
+		return entry, true, ""
+	}
+
+	return AuthToken{}, false, "unknown token"
+}
+
+func (s *AuthStore) consume(key string, thinkingTokens int) {
+	if key == "" {
+		return
+	}
+	s.usageFor(key).consume(thinkingTokens, time.Now())
+}
+
+// pruneStale drops usage counters for tokens no longer present in tokens,
+// so a config reload that removes or renames a token doesn't leak its
+// entry in the usage map forever.
+func (s *AuthStore) pruneStale(tokens []AuthToken) {
+	keep := make(map[string]bool, len(tokens))
+	for _, entry := range tokens {
+		keep[entry.key()] = true
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for key := range s.usage {
+		if !keep[key] {
+			delete(s.usage, key)
+		}
+	}
+}
+
+// tokenStatus is the JSON shape served by /admin/tokens.
+type tokenStatus struct {
+	Name             string `json:"name"`
+	AllowedModel     string `json:"allowedModel,omitempty"`
+	DailyRemaining   *int   `json:"dailyRemaining,omitempty"`
+	MonthlyRemaining *int   `json:"monthlyRemaining,omitempty"`
+}
+
+// tokensHandler serves /admin/tokens: a JSON array of every configured
+// token's remaining daily/monthly thinking-token quota.
+func tokensHandler(config *ConfigProvider) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		cfg := config.Load()
+		statuses := make([]tokenStatus, 0, len(cfg.AuthTokens))
+
+		for _, entry := range cfg.AuthTokens {
+			status := tokenStatus{Name: entry.label(), AllowedModel: entry.AllowedModel}
+			daily, monthly := authStore.usageFor(entry.key()).remaining(entry.DailyThinkingBudget, entry.MonthlyThinkingBudget, time.Now())
+			if entry.DailyThinkingBudget > 0 {
+				status.DailyRemaining = &daily
+			}
+			if entry.MonthlyThinkingBudget > 0 {
+				status.MonthlyRemaining = &monthly
+			}
+			statuses = append(statuses, status)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(statuses)
+	}
+}
@@ -0,0 +1,245 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestAuthTokenMatches(t *testing.T) {
+	hash := sha256.Sum256([]byte("s3cret"))
+	hashed := hex.EncodeToString(hash[:])
+
+	tests := []struct {
+		name      string
+		token     AuthToken
+		candidate string
+		want      bool
+	}{
+		{"plaintext match", AuthToken{Token: "abc123"}, "abc123", true},
+		{"plaintext mismatch", AuthToken{Token: "abc123"}, "wrong", false},
+		{"empty candidate never matches", AuthToken{Token: "abc123"}, "", false},
+		{"hash match is case-insensitive", AuthToken{TokenHash: hashed}, "s3cret", true},
+		{"hash mismatch", AuthToken{TokenHash: hashed}, "other", false},
+		{"neither token nor hash set", AuthToken{Name: "no-credential"}, "anything", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.token.matches(tt.candidate); got != tt.want {
+				t.Errorf("matches(%q) = %v, want %v", tt.candidate, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAuthTokenLabelNeverLeaksRawCredential(t *testing.T) {
+	hash := sha256.Sum256([]byte("s3cret"))
+	hashed := hex.EncodeToString(hash[:])
+
+	tests := []struct {
+		name  string
+		token AuthToken
+	}{
+		{"named token", AuthToken{Name: "prod-key", Token: "super-secret-value"}},
+		{"plaintext token without a name", AuthToken{Token: "super-secret-value"}},
+		{"hashed token without a name", AuthToken{TokenHash: hashed}},
+		{"no credential at all", AuthToken{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			label := tt.token.label()
+			if tt.token.Token != "" && label == tt.token.Token {
+				t.Fatalf("label() returned the raw plaintext token: %q", label)
+			}
+			if label == "" {
+				t.Fatal("label() must never be empty")
+			}
+		})
+	}
+
+	if got := (AuthToken{Name: "prod-key"}).label(); got != "prod-key" {
+		t.Fatalf("label() = %q, want the configured Name %q", got, "prod-key")
+	}
+}
+
+func TestAuthTokenAllowsModel(t *testing.T) {
+	tests := []struct {
+		name         string
+		allowedModel string
+		model        string
+		want         bool
+	}{
+		{"empty allowlist permits everything", "", "claude-opus-4", true},
+		{"glob prefix match", "claude-*", "claude-3-5-haiku-20241022", true},
+		{"glob prefix non-match", "claude-*", "gpt-4o", false},
+		{"exact match", "claude-3-5-haiku-20241022", "claude-3-5-haiku-20241022", true},
+		{"exact pattern does not prefix-match a longer model name", "claude-3-5-haiku-20241022", "claude-3-5-haiku-20241022-thinking-999999", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			token := AuthToken{AllowedModel: tt.allowedModel}
+			if got := token.allowsModel(tt.model); got != tt.want {
+				t.Errorf("allowsModel(%q) with AllowedModel=%q = %v, want %v", tt.model, tt.allowedModel, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExtractBearerToken(t *testing.T) {
+	tests := []struct {
+		name    string
+		headers map[string]string
+		want    string
+	}{
+		{"authorization bearer header", map[string]string{"Authorization": "Bearer abc123"}, "abc123"},
+		{"x-proxy-token header", map[string]string{"X-Proxy-Token": "abc123"}, "abc123"},
+		{"authorization without bearer prefix is ignored", map[string]string{"Authorization": "Basic abc123"}, ""},
+		{"no headers", map[string]string{}, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequest(http.MethodGet, "/", nil)
+			for k, v := range tt.headers {
+				req.Header.Set(k, v)
+			}
+			if got := extractBearerToken(req); got != tt.want {
+				t.Errorf("extractBearerToken() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTokenUsageRemainingAndConsume(t *testing.T) {
+	usage := &tokenUsage{}
+	now := time.Date(2026, 7, 26, 12, 0, 0, 0, time.UTC)
+
+	daily, monthly := usage.remaining(100, 1000, now)
+	if daily != 100 || monthly != 1000 {
+		t.Fatalf("initial remaining = (%d, %d), want (100, 1000)", daily, monthly)
+	}
+
+	usage.consume(40, now)
+	daily, monthly = usage.remaining(100, 1000, now)
+	if daily != 60 || monthly != 960 {
+		t.Fatalf("after consuming 40, remaining = (%d, %d), want (60, 960)", daily, monthly)
+	}
+
+	if daily, _ := usage.remaining(0, 1000, now); daily != unlimitedBudget {
+		t.Fatalf("a zero dailyBudget should report unlimitedBudget, got %d", daily)
+	}
+}
+
+func TestTokenUsageRolloverAcrossDayAndMonthBoundaries(t *testing.T) {
+	usage := &tokenUsage{}
+	day1 := time.Date(2026, 7, 26, 23, 59, 0, 0, time.UTC)
+	usage.consume(50, day1)
+
+	day2 := day1.Add(2 * time.Minute)
+	daily, monthly := usage.remaining(100, 1000, day2)
+	if daily != 100 {
+		t.Fatalf("daily usage should reset across midnight, got remaining=%d", daily)
+	}
+	if monthly != 950 {
+		t.Fatalf("monthly usage should carry over within the same month, got remaining=%d", monthly)
+	}
+
+	nextMonth := time.Date(2026, 8, 1, 0, 0, 0, 0, time.UTC)
+	daily, monthly = usage.remaining(100, 1000, nextMonth)
+	if daily != 100 || monthly != 1000 {
+		t.Fatalf("usage should reset across a month boundary, got (%d, %d)", daily, monthly)
+	}
+}
+
+func TestAuthStoreAuthorize(t *testing.T) {
+	cfg := ProxyConfig{
+		AuthTokens: []AuthToken{
+			{Name: "limited", Token: "tok-limited", AllowedModel: "claude-*", DailyThinkingBudget: 10},
+			{Name: "unrestricted", Token: "tok-all"},
+		},
+	}
+
+	t.Run("no tokens configured disables auth", func(t *testing.T) {
+		_, ok, _ := newAuthStore().authorize(ProxyConfig{}, mustRequest(t, ""), "claude-opus-4")
+		if !ok {
+			t.Fatal("expected auth to be disabled when AuthTokens is empty")
+		}
+	})
+
+	t.Run("missing bearer token is rejected", func(t *testing.T) {
+		_, ok, reason := newAuthStore().authorize(cfg, mustRequest(t, ""), "claude-opus-4")
+		if ok || reason == "" {
+			t.Fatalf("expected rejection with a reason, got ok=%v reason=%q", ok, reason)
+		}
+	})
+
+	t.Run("unknown token is rejected", func(t *testing.T) {
+		_, ok, _ := newAuthStore().authorize(cfg, mustRequest(t, "not-a-real-token"), "claude-opus-4")
+		if ok {
+			t.Fatal("expected rejection for an unknown token")
+		}
+	})
+
+	t.Run("model outside allowlist is rejected", func(t *testing.T) {
+		_, ok, _ := newAuthStore().authorize(cfg, mustRequest(t, "tok-limited"), "gpt-4o")
+		if ok {
+			t.Fatal("expected rejection for a model outside the token's allowlist")
+		}
+	})
+
+	t.Run("exhausted quota is rejected", func(t *testing.T) {
+		store := newAuthStore()
+		store.consume("limited", 10)
+		_, ok, _ := store.authorize(cfg, mustRequest(t, "tok-limited"), "claude-opus-4")
+		if ok {
+			t.Fatal("expected rejection once the daily thinking-token quota is exhausted")
+		}
+	})
+
+	t.Run("matching token within quota is allowed", func(t *testing.T) {
+		entry, ok, reason := newAuthStore().authorize(cfg, mustRequest(t, "tok-all"), "claude-opus-4")
+		if !ok {
+			t.Fatalf("expected request to be allowed, got reason=%q", reason)
+		}
+		if entry.Name != "unrestricted" {
+			t.Fatalf("authorize returned entry %q, want %q", entry.Name, "unrestricted")
+		}
+	})
+}
+
+func TestAuthStorePruneStale(t *testing.T) {
+	store := newAuthStore()
+	store.consume("keep-me", 5)
+	store.consume("remove-me", 5)
+
+	store.pruneStale([]AuthToken{{Name: "keep-me"}})
+
+	store.mu.Lock()
+	_, kept := store.usage["keep-me"]
+	_, removed := store.usage["remove-me"]
+	store.mu.Unlock()
+
+	if !kept {
+		t.Fatal("pruneStale removed a token still present in the config")
+	}
+	if removed {
+		t.Fatal("pruneStale left a usage entry for a token no longer in the config")
+	}
+}
+
+func mustRequest(t *testing.T, bearerToken string) *http.Request {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodPost, "/v1/messages", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bearerToken != "" {
+		req.Header.Set("Authorization", "Bearer "+bearerToken)
+	}
+	return req
+}
@@ -0,0 +1,234 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// ConfigProvider watches path for changes and keeps an atomically-swapped,
+// fully-validated ProxyConfig available to the hot request path without
+// locking. A config that fails validation is logged and ignored: the last
+// known-good config stays live, and LastError reports why the reload was
+// skipped.
+type ConfigProvider struct {
+	path string
+	cfg  atomic.Pointer[ProxyConfig]
+
+	mu          sync.Mutex
+	lastErr     error
+	subscribers []func(old, new ProxyConfig)
+}
+
+// NewConfigProvider loads path once synchronously, then starts a background
+// watcher that reloads on every subsequent change.
+func NewConfigProvider(path string) *ConfigProvider {
+	p := &ConfigProvider{path: path}
+
+	initial, err := loadAndValidate(path)
+	if err != nil {
+		log.Printf("[ThinkingProxy] Initial config load failed, starting with an empty config: %v", err)
+		p.setLastErr(err)
+		initial = ProxyConfig{}
+	}
+	p.cfg.Store(&initial)
+
+	go p.watch()
+	return p
+}
+
+// Load returns the current config. It never blocks on the watcher.
+func (p *ConfigProvider) Load() ProxyConfig {
+	if cfg := p.cfg.Load(); cfg != nil {
+		return *cfg
+	}
+	return ProxyConfig{}
+}
+
+// LastError reports the error from the most recent failed reload attempt,
+// or nil if the last reload (or the initial load) succeeded.
+func (p *ConfigProvider) LastError() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.lastErr
+}
+
+// Subscribe registers fn to be called, with the old and new config, every
+// time a reload successfully swaps in a new config. fn is called from the
+// watcher goroutine, after the swap, so subscribers always observe the
+// config that Load() would now return as "new".
+func (p *ConfigProvider) Subscribe(fn func(old, new ProxyConfig)) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.subscribers = append(p.subscribers, fn)
+}
+
+func (p *ConfigProvider) setLastErr(err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.lastErr = err
+}
+
+// watch follows the fsnotify rename-on-save pattern most editors and config
+// managers use: watch the containing directory rather than the file itself,
+// since a rename replaces the watched inode and would silently stop
+// delivering events.
+func (p *ConfigProvider) watch() {
+	if p.path == "" {
+		return
+	}
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		log.Printf("[ThinkingProxy] Config watcher unavailable, reloads disabled: %v", err)
+		return
+	}
+	defer watcher.Close()
+
+	dir := filepath.Dir(p.path)
+	if err := watcher.Add(dir); err != nil {
+		log.Printf("[ThinkingProxy] Failed to watch %s, reloads disabled: %v", dir, err)
+		return
+	}
+
+	target := filepath.Clean(p.path)
+	for {
+		select {
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return
+			}
+			if filepath.Clean(event.Name) != target {
+				continue
+			}
+			if event.Op&(fsnotify.Write|fsnotify.Create|fsnotify.Rename) == 0 {
+				continue
+			}
+			p.reload()
+
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return
+			}
+			log.Printf("[ThinkingProxy] Config watcher error: %v", err)
+		}
+	}
+}
+
+// reload validates the file at p.path and, on success, atomically swaps it
+// in and notifies subscribers. On failure the last-good config is kept live
+// and LastError is set so a bad edit is observable instead of invisible.
+func (p *ConfigProvider) reload() {
+	newCfg, err := loadAndValidate(p.path)
+	if err != nil {
+		log.Printf("[ThinkingProxy] Config reload failed, keeping last-good config: %v", err)
+		p.setLastErr(err)
+		return
+	}
+	p.setLastErr(nil)
+
+	old := p.Load()
+	p.cfg.Store(&newCfg)
+	log.Printf("[ThinkingProxy] Config reloaded from %s", p.path)
+
+	p.mu.Lock()
+	subscribers := append([]func(old, new ProxyConfig){}, p.subscribers...)
+	p.mu.Unlock()
+
+	for _, fn := range subscribers {
+		fn(old, newCfg)
+	}
+}
+
+func loadAndValidate(path string) (ProxyConfig, error) {
+	if path == "" {
+		return ProxyConfig{}, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return ProxyConfig{}, fmt.Errorf("read %s: %w", path, err)
+	}
+
+	var cfg ProxyConfig
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return ProxyConfig{}, fmt.Errorf("parse %s: %w", path, err)
+	}
+
+	if err := validateConfig(cfg); err != nil {
+		return ProxyConfig{}, fmt.Errorf("validate %s: %w", path, err)
+	}
+
+	return cfg, nil
+}
+
+// validateConfig rejects a config before it ever reaches the atomic
+// pointer, so a broken edit never takes effect instead of failing midway
+// through a request.
+func validateConfig(cfg ProxyConfig) error {
+	if cfg.VercelEnabled && strings.TrimSpace(cfg.VercelApiKey) == "" {
+		return fmt.Errorf("vercelEnabled is true but vercelApiKey is empty")
+	}
+
+	for _, route := range cfg.Routes {
+		if route.Provider != "" && !knownProviders[route.Provider] {
+			return fmt.Errorf("route %q: unknown provider %q", route.Name, route.Provider)
+		}
+		if route.BaseURL != "" {
+			if _, err := url.Parse(route.BaseURL); err != nil {
+				return fmt.Errorf("route %q: invalid baseUrl %q: %w", route.Name, route.BaseURL, err)
+			}
+		}
+		if route.Provider == ProviderBedrock {
+			if strings.TrimSpace(route.Region) == "" {
+				return fmt.Errorf("route %q: bedrock routes require region", route.Name)
+			}
+			if _, _, ok := route.bedrockCredentials(); !ok {
+				return fmt.Errorf("route %q: bedrock routes require a credential in \"<accessKeyID>:<secretAccessKey>\" form", route.Name)
+			}
+		}
+	}
+
+	seen := make(map[string]bool, len(cfg.AuthTokens))
+	for _, token := range cfg.AuthTokens {
+		key := token.key()
+		if key == "" {
+			return fmt.Errorf("authToken %q: must set token, tokenHash, or name", token.Name)
+		}
+		if seen[key] {
+			return fmt.Errorf("authToken %q: duplicate token entry", key)
+		}
+		seen[key] = true
+	}
+
+	return nil
+}
+
+// configStatus is the JSON shape served by /admin/config/status.
+type configStatus struct {
+	LastError string `json:"lastError,omitempty"`
+}
+
+// configStatusHandler serves /admin/config/status: whether the most recent
+// config reload succeeded, so a broken edit is observable instead of
+// silently keeping the last-good config.
+func configStatusHandler(config *ConfigProvider) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		status := configStatus{}
+		if err := config.LastError(); err != nil {
+			status.LastError = err.Error()
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(status)
+	}
+}
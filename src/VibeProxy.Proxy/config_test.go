@@ -0,0 +1,193 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLoadAndValidateSuccess(t *testing.T) {
+	path := writeConfigFile(t, `{"routes":[{"name":"r1","model":"claude-*","baseUrl":"https://example.com"}]}`)
+
+	cfg, err := loadAndValidate(path)
+	if err != nil {
+		t.Fatalf("loadAndValidate() error = %v, want nil", err)
+	}
+	if len(cfg.Routes) != 1 || cfg.Routes[0].Name != "r1" {
+		t.Fatalf("loadAndValidate() cfg = %+v, want one route named r1", cfg)
+	}
+}
+
+func TestLoadAndValidateEmptyPath(t *testing.T) {
+	cfg, err := loadAndValidate("")
+	if err != nil {
+		t.Fatalf("loadAndValidate(\"\") error = %v, want nil", err)
+	}
+	if len(cfg.Routes) != 0 {
+		t.Fatalf("loadAndValidate(\"\") = %+v, want a zero-value ProxyConfig", cfg)
+	}
+}
+
+func TestLoadAndValidateFailurePaths(t *testing.T) {
+	t.Run("missing file", func(t *testing.T) {
+		if _, err := loadAndValidate(filepath.Join(t.TempDir(), "does-not-exist.json")); err == nil {
+			t.Fatal("expected an error for a missing config file")
+		}
+	})
+
+	t.Run("invalid JSON", func(t *testing.T) {
+		path := writeConfigFile(t, `{not json`)
+		if _, err := loadAndValidate(path); err == nil {
+			t.Fatal("expected an error for malformed JSON")
+		}
+	})
+
+	t.Run("fails validateConfig", func(t *testing.T) {
+		path := writeConfigFile(t, `{"routes":[{"name":"r1","provider":"not-a-real-provider"}]}`)
+		if _, err := loadAndValidate(path); err == nil {
+			t.Fatal("expected an error for an unknown provider")
+		}
+	})
+}
+
+func TestValidateConfigRejectsUnknownProvider(t *testing.T) {
+	cfg := ProxyConfig{Routes: []Route{{Name: "r1", Provider: "not-a-real-provider"}}}
+	if err := validateConfig(cfg); err == nil {
+		t.Fatal("expected an error for an unknown provider")
+	}
+}
+
+func TestValidateConfigBedrockRequiresRegionAndCredential(t *testing.T) {
+	t.Run("missing region", func(t *testing.T) {
+		cfg := ProxyConfig{Routes: []Route{{Name: "r1", Provider: ProviderBedrock, CredentialEnv: "BEDROCK_TEST_CREDS"}}}
+		t.Setenv("BEDROCK_TEST_CREDS", "AKIDEXAMPLE:secret")
+		if err := validateConfig(cfg); err == nil {
+			t.Fatal("expected an error for a bedrock route with no region")
+		}
+	})
+
+	t.Run("missing credential", func(t *testing.T) {
+		cfg := ProxyConfig{Routes: []Route{{Name: "r1", Provider: ProviderBedrock, Region: "us-east-1"}}}
+		if err := validateConfig(cfg); err == nil {
+			t.Fatal("expected an error for a bedrock route with no credential")
+		}
+	})
+
+	t.Run("region and credential present", func(t *testing.T) {
+		t.Setenv("BEDROCK_TEST_CREDS", "AKIDEXAMPLE:secret")
+		cfg := ProxyConfig{Routes: []Route{{Name: "r1", Provider: ProviderBedrock, Region: "us-east-1", CredentialEnv: "BEDROCK_TEST_CREDS"}}}
+		if err := validateConfig(cfg); err != nil {
+			t.Fatalf("validateConfig() error = %v, want nil", err)
+		}
+	})
+}
+
+func TestNewConfigProviderFallsBackToEmptyConfigOnInitialLoadFailure(t *testing.T) {
+	path := writeConfigFile(t, `{not json`)
+
+	provider := NewConfigProvider(path)
+	if cfg := provider.Load(); len(cfg.Routes) != 0 {
+		t.Fatalf("Load() = %+v, want an empty ProxyConfig", cfg)
+	}
+	if provider.LastError() == nil {
+		t.Fatal("expected LastError to report the initial load failure")
+	}
+}
+
+// TestConfigProviderReloadsAndNotifiesSubscribers exercises the full
+// lifecycle this type exists for: a background fsnotify watcher picking up
+// an on-disk edit, validating it, atomically swapping it in, and notifying
+// Subscribe callbacks with the old and new config.
+func TestConfigProviderReloadsAndNotifiesSubscribers(t *testing.T) {
+	path := writeConfigFile(t, `{"routes":[{"name":"r1","model":"claude-*"}]}`)
+
+	provider := NewConfigProvider(path)
+	if got := provider.Load(); len(got.Routes) != 1 || got.Routes[0].Name != "r1" {
+		t.Fatalf("initial Load() = %+v, want one route named r1", got)
+	}
+
+	type swap struct{ old, new ProxyConfig }
+	notified := make(chan swap, 1)
+	provider.Subscribe(func(old, new ProxyConfig) {
+		notified <- swap{old, new}
+	})
+
+	// Give the background watcher goroutine time to start and register its
+	// fsnotify watch before we write, since fsnotify delivers nothing for
+	// writes that happen before watcher.Add completes.
+	time.Sleep(200 * time.Millisecond)
+	overwriteConfigFile(t, path, `{"routes":[{"name":"r2","model":"gpt-*"}]}`)
+
+	select {
+	case s := <-notified:
+		if len(s.old.Routes) != 1 || s.old.Routes[0].Name != "r1" {
+			t.Fatalf("Subscribe old = %+v, want the pre-reload route r1", s.old)
+		}
+		if len(s.new.Routes) != 1 || s.new.Routes[0].Name != "r2" {
+			t.Fatalf("Subscribe new = %+v, want the reloaded route r2", s.new)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for the config watcher to reload and notify")
+	}
+
+	if got := provider.Load(); len(got.Routes) != 1 || got.Routes[0].Name != "r2" {
+		t.Fatalf("Load() after reload = %+v, want the reloaded route r2", got)
+	}
+	if provider.LastError() != nil {
+		t.Fatalf("LastError() = %v, want nil after a valid reload", provider.LastError())
+	}
+}
+
+// TestConfigProviderKeepsLastGoodConfigOnBadReload guards the documented
+// failure mode: a broken edit must not take effect, and must surface
+// through LastError instead of silently leaving the previous config live
+// with no visibility into why the reload was skipped.
+func TestConfigProviderKeepsLastGoodConfigOnBadReload(t *testing.T) {
+	path := writeConfigFile(t, `{"routes":[{"name":"r1","model":"claude-*"}]}`)
+
+	provider := NewConfigProvider(path)
+
+	lastErrCleared := make(chan struct{}, 1)
+	provider.Subscribe(func(old, new ProxyConfig) {
+		lastErrCleared <- struct{}{}
+	})
+
+	time.Sleep(200 * time.Millisecond)
+	overwriteConfigFile(t, path, `{not json`)
+
+	deadline := time.After(5 * time.Second)
+	for provider.LastError() == nil {
+		select {
+		case <-time.After(50 * time.Millisecond):
+		case <-deadline:
+			t.Fatal("timed out waiting for the config watcher to observe the bad reload")
+		}
+	}
+
+	if got := provider.Load(); len(got.Routes) != 1 || got.Routes[0].Name != "r1" {
+		t.Fatalf("Load() after a failed reload = %+v, want the last-good route r1 unchanged", got)
+	}
+
+	select {
+	case <-lastErrCleared:
+		t.Fatal("Subscribe fired for a reload that should have failed validation")
+	default:
+	}
+}
+
+func writeConfigFile(t *testing.T, contents string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(path, []byte(contents), 0o600); err != nil {
+		t.Fatalf("failed to write test config: %v", err)
+	}
+	return path
+}
+
+func overwriteConfigFile(t *testing.T, path, contents string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(contents), 0o600); err != nil {
+		t.Fatalf("failed to overwrite test config: %v", err)
+	}
+}
@@ -2,84 +2,49 @@ package main
 
 import (
 	"bytes"
-	"crypto/tls"
 	"encoding/json"
 	"flag"
 	"fmt"
 	"io"
 	"log"
 	"net/http"
-	"os"
+	"net/url"
 	"strconv"
 	"strings"
-	"sync"
 	"time"
 )
 
 const (
-	hardTokenCap     = 32000
-	minimumHeadroom  = 1024
-	headroomRatio    = 0.1
+	hardTokenCap      = 32000
+	minimumHeadroom   = 1024
+	headroomRatio     = 0.1
 	vercelGatewayHost = "ai-gateway.vercel.sh"
-	anthropicVersion = "2023-06-01"
+	anthropicVersion  = "2023-06-01"
 	betaThinking      = "interleaved-thinking-2025-05-14"
 )
 
 type ProxyConfig struct {
-	VercelEnabled bool   `json:"vercelEnabled"`
-	VercelApiKey  string `json:"vercelApiKey"`
+	VercelEnabled bool        `json:"vercelEnabled"`
+	VercelApiKey  string      `json:"vercelApiKey"`
+	Routes        []Route     `json:"routes"`
+	AuthTokens    []AuthToken `json:"authTokens"`
 }
 
 func (c ProxyConfig) IsActive() bool {
 	return c.VercelEnabled && strings.TrimSpace(c.VercelApiKey) != ""
 }
 
-type ConfigProvider struct {
-	path string
-	mu   sync.Mutex
-	last time.Time
-	cfg  ProxyConfig
-}
-
-func NewConfigProvider(path string) *ConfigProvider {
-	return &ConfigProvider{path: path}
-}
-
-func (p *ConfigProvider) Load() ProxyConfig {
-	p.mu.Lock()
-	defer p.mu.Unlock()
-
-	info, err := os.Stat(p.path)
-	if err != nil {
-		return p.cfg
-	}
-
-	if info.ModTime().Equal(p.last) {
-		return p.cfg
-	}
-
-	data, err := os.ReadFile(p.path)
-	if err != nil {
-		return p.cfg
-	}
-
-	var cfg ProxyConfig
-	if err := json.Unmarshal(data, &cfg); err != nil {
-		return p.cfg
-	}
-
-	p.last = info.ModTime()
-	p.cfg = cfg
-	return cfg
-}
-
 func main() {
 	listenPort := flag.Int("listen", 8317, "listen port")
 	targetPort := flag.Int("target", 8318, "backend port")
 	configPath := flag.String("config", "", "config file path")
+	adminPort := flag.Int("admin", 0, "admin listener port for /metrics (0 disables it)")
 	flag.Parse()
 
 	provider := NewConfigProvider(*configPath)
+	provider.Subscribe(func(old, new ProxyConfig) {
+		authStore.pruneStale(new.AuthTokens)
+	})
 
 	proxy := &Proxy{
 		listenAddr: fmt.Sprintf("127.0.0.1:%d", *listenPort),
@@ -87,6 +52,10 @@ func main() {
 		config:     provider,
 	}
 
+	if *adminPort != 0 {
+		startAdminServer(fmt.Sprintf("127.0.0.1:%d", *adminPort), provider)
+	}
+
 	srv := &http.Server{
 		Addr:              proxy.listenAddr,
 		Handler:           proxy,
@@ -106,8 +75,18 @@ type Proxy struct {
 }
 
 func (p *Proxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	metrics.incInFlight()
+	defer metrics.decInFlight()
+
+	recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+	p.serveHTTP(recorder, r)
+	metrics.recordRequest(r.Method, r.URL.Path, recorder.status)
+}
+
+func (p *Proxy) serveHTTP(w http.ResponseWriter, r *http.Request) {
 	bodyBytes, _ := io.ReadAll(r.Body)
 	_ = r.Body.Close()
+	metrics.addBytesIn(len(bodyBytes))
 
 	method := r.Method
 	path := r.URL.Path
@@ -135,74 +114,103 @@ func (p *Proxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	if r.URL.RawQuery != "" {
 		forwardPath += "?" + r.URL.RawQuery
 	}
+
+	cfg := p.config.Load()
+
 	if !isProviderPath && !isCliProxyPath {
+		if route, found := resolveRoute(cfg, "", path, []Route{defaultAccountRoute()}); found {
+			metrics.recordRoutingDecision(string(route.Provider))
+			p.forwardToProvider(route, w, r, forwardPath, bodyBytes, false)
+			return
+		}
+		metrics.recordRoutingDecision("local")
 		p.forwardToAmp(w, r, forwardPath, bodyBytes)
 		return
 	}
 
+	rawModel, _ := extractModel(bodyBytes)
+	effectiveModel := stripThinkingBudgetSuffix(rawModel)
+	authEntry, allowed, reason := authStore.authorize(cfg, r, effectiveModel)
+	if !allowed {
+		log.Printf("[ThinkingProxy] Rejected request (model=%q): %s", effectiveModel, reason)
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	r = withAuthTokenKey(r, authEntry.key())
+
 	modifiedBody := bodyBytes
 	thinkingEnabled := false
 	if method == http.MethodPost && len(bodyBytes) > 0 {
-		if transformed, enabled := processThinking(bodyBytes); transformed != nil {
+		if transformed, enabled, budget := processThinking(bodyBytes, authEntry.MaxBudgetTokens); transformed != nil {
 			modifiedBody = transformed
 			thinkingEnabled = enabled
+			r = withThinkingBudget(r, budget)
 		}
 	}
 
-	if p.config.Load().IsActive() && method == http.MethodPost && isClaudeModel(modifiedBody) {
-		p.forwardToVercel(w, r, modifiedBody, thinkingEnabled)
-		return
+	if method == http.MethodPost {
+		if model, ok := extractModel(modifiedBody); ok {
+			if route, found := resolveRoute(cfg, model, path, defaultModelRoutes(cfg)); found {
+				metrics.recordRoutingDecision(string(route.Provider))
+				p.forwardToProvider(route, w, r, forwardPath, modifiedBody, thinkingEnabled)
+				return
+			}
+		}
 	}
 
+	metrics.recordRoutingDecision("local")
 	p.forwardToBackend(w, r, forwardPath, modifiedBody, thinkingEnabled)
 }
 
-func processThinking(body []byte) ([]byte, bool) {
+// processThinking parses the "-thinking-<budget>" suffix off a request's
+// model name and turns it into an Anthropic extended-thinking request.
+// budgetCeiling, when positive, overrides hardTokenCap as the maximum
+// budget allowed (used to enforce an AuthToken's forced MaxBudgetTokens).
+func processThinking(body []byte, budgetCeiling int) ([]byte, bool, int) {
+	budgetCap := hardTokenCap
+	if budgetCeiling > 0 && budgetCeiling < budgetCap {
+		budgetCap = budgetCeiling
+	}
+
 	var payload map[string]interface{}
 	if err := json.Unmarshal(body, &payload); err != nil {
-		return nil, false
+		return nil, false, 0
 	}
 
 	modelValue, ok := payload["model"].(string)
 	if !ok {
-		return nil, false
+		return nil, false, 0
 	}
 
 	if !strings.HasPrefix(modelValue, "claude-") && !strings.HasPrefix(modelValue, "gemini-claude-") {
-		return body, false
+		return body, false, 0
 	}
 
 	thinkingIndex := strings.LastIndex(modelValue, "-thinking-")
 	if thinkingIndex == -1 {
 		if strings.HasSuffix(modelValue, "-thinking") || strings.Contains(modelValue, "-thinking(") {
 			log.Printf("[ThinkingProxy] Detected thinking model '%s' - enabling beta header", modelValue)
-			return body, true
+			return body, true, 0
 		}
-		return body, false
+		return body, false, 0
 	}
 
 	budgetString := modelValue[thinkingIndex+len("-thinking-"):]
-
-	cleanModel := ""
-	if strings.HasPrefix(modelValue, "gemini-claude-") {
-		prefix := modelValue[:thinkingIndex+len("-thinking-")]
-		cleanModel = strings.TrimSuffix(prefix, "-")
-	} else {
-		cleanModel = modelValue[:thinkingIndex]
-	}
+	cleanModel := stripThinkingBudgetSuffix(modelValue)
 
 	payload["model"] = cleanModel
 
 	budget, err := strconv.Atoi(budgetString)
 	if err != nil || budget <= 0 {
 		log.Printf("[ThinkingProxy] Stripped invalid thinking suffix from '%s' -> '%s'", modelValue, cleanModel)
-		return marshalJSON(payload), true
+		return marshalJSON(payload), true, 0
 	}
 
 	effectiveBudget := budget
-	if effectiveBudget > hardTokenCap-1 {
-		effectiveBudget = hardTokenCap - 1
+	if effectiveBudget > budgetCap-1 {
+		effectiveBudget = budgetCap - 1
 		log.Printf("[ThinkingProxy] Adjusted thinking budget from %d to %d", budget, effectiveBudget)
+		metrics.recordBudgetAdjustment()
 	}
 
 	payload["thinking"] = map[string]interface{}{
@@ -217,13 +225,13 @@ func processThinking(body []byte) ([]byte, bool) {
 	}
 	desiredMax := effectiveBudget + tokenHeadroom
 	requiredMax := desiredMax
-	if requiredMax > hardTokenCap {
-		requiredMax = hardTokenCap
+	if requiredMax > budgetCap {
+		requiredMax = budgetCap
 	}
 	if requiredMax <= effectiveBudget {
 		requiredMax = effectiveBudget + 1
-		if requiredMax > hardTokenCap {
-			requiredMax = hardTokenCap
+		if requiredMax > budgetCap {
+			requiredMax = budgetCap
 		}
 	}
 
@@ -251,7 +259,8 @@ func processThinking(body []byte) ([]byte, bool) {
 	}
 
 	log.Printf("[ThinkingProxy] Transformed model '%s' -> '%s' with thinking budget %d", modelValue, cleanModel, effectiveBudget)
-	return marshalJSON(payload), true
+	metrics.recordThinkingTransform(modelValue, cleanModel)
+	return marshalJSON(payload), true, effectiveBudget
 }
 
 func marshalJSON(payload map[string]interface{}) []byte {
@@ -262,39 +271,43 @@ func marshalJSON(payload map[string]interface{}) []byte {
 	return data
 }
 
-func isClaudeModel(body []byte) bool {
+// stripThinkingBudgetSuffix strips a "-thinking-<budget>" suffix off a
+// model name, mirroring the renaming processThinking applies to the
+// outgoing request body. Used to check an AuthToken's AllowedModel against
+// the model the request will actually reach upstream as, rather than the
+// raw client-supplied name - otherwise an exact AllowedModel entry would
+// reject every request using the documented thinking-suffix convention for
+// that same model.
+func stripThinkingBudgetSuffix(model string) string {
+	thinkingIndex := strings.LastIndex(model, "-thinking-")
+	if thinkingIndex == -1 {
+		return model
+	}
+	if strings.HasPrefix(model, "gemini-claude-") {
+		prefix := model[:thinkingIndex+len("-thinking-")]
+		return strings.TrimSuffix(prefix, "-")
+	}
+	return model[:thinkingIndex]
+}
+
+func extractModel(body []byte) (string, bool) {
 	var payload map[string]interface{}
 	if err := json.Unmarshal(body, &payload); err != nil {
-		return false
+		return "", false
 	}
 
 	model, ok := payload["model"].(string)
 	if !ok {
-		return false
+		return "", false
 	}
 
-	return strings.HasPrefix(model, "claude-") || strings.HasPrefix(model, "gemini-claude-")
+	return model, true
 }
 
 func (p *Proxy) forwardToBackend(w http.ResponseWriter, r *http.Request, path string, body []byte, thinkingEnabled bool) {
 	p.forwardRequestWithRetry(w, r, path, body, thinkingEnabled, true)
 }
 
-func (p *Proxy) forwardToVercel(w http.ResponseWriter, r *http.Request, body []byte, thinkingEnabled bool) {
-	cfg := p.config.Load()
-	targetURL := fmt.Sprintf("https://%s/v1/messages", vercelGatewayHost)
-
-	transport := &http.Transport{
-		TLSClientConfig: &tls.Config{ServerName: vercelGatewayHost},
-	}
-
-	p.forwardRequestWithClient(w, r, targetURL, body, thinkingEnabled, transport, func(req *http.Request) {
-		req.Header.Set("x-api-key", cfg.VercelApiKey)
-		req.Header.Set("anthropic-version", anthropicVersion)
-		req.Header.Set("content-type", "application/json")
-	})
-}
-
 func (p *Proxy) forwardToAmp(w http.ResponseWriter, r *http.Request, path string, body []byte) {
 	targetURL := fmt.Sprintf("https://ampcode.com%s", path)
 
@@ -305,8 +318,8 @@ func (p *Proxy) forwardToAmp(w http.ResponseWriter, r *http.Request, path string
 }
 
 func (p *Proxy) forwardRequestWithRetry(w http.ResponseWriter, r *http.Request, path string, body []byte, thinkingEnabled bool, allowRetry bool) {
-	targetURL := fmt.Sprintf("http://%s%s", p.targetAddr, path)
-	resp, err := p.executeRequest(r, targetURL, body, thinkingEnabled, http.DefaultTransport, nil)
+	start := time.Now()
+	resp, err := p.executeRequest(r, fmt.Sprintf("http://%s%s", p.targetAddr, path), body, thinkingEnabled, http.DefaultTransport, nil)
 	if err != nil {
 		http.Error(w, "Bad Gateway", http.StatusBadGateway)
 		return
@@ -317,6 +330,7 @@ func (p *Proxy) forwardRequestWithRetry(w http.ResponseWriter, r *http.Request,
 		_ = resp.Body.Close()
 		newPath := "/api" + path
 		log.Printf("[ThinkingProxy] Got 404 for %s, retrying with %s", path, newPath)
+		metrics.recordRetry404()
 		p.forwardRequestWithRetry(w, r, newPath, body, thinkingEnabled, false)
 		return
 	}
@@ -327,10 +341,18 @@ func (p *Proxy) forwardRequestWithRetry(w http.ResponseWriter, r *http.Request,
 		}
 	}
 	w.WriteHeader(resp.StatusCode)
-	_, _ = io.Copy(w, resp.Body)
+
+	if isSSEResponse(resp) {
+		streamSSE(r.Context(), w, resp.Body, start)
+		return
+	}
+
+	n, _ := io.Copy(w, resp.Body)
+	metrics.addBytesOut(n)
 }
 
 func (p *Proxy) forwardRequestWithClient(w http.ResponseWriter, r *http.Request, targetURL string, body []byte, thinkingEnabled bool, transport http.RoundTripper, tweak func(req *http.Request)) {
+	start := time.Now()
 	resp, err := p.executeRequest(r, targetURL, body, thinkingEnabled, transport, tweak)
 	if err != nil {
 		http.Error(w, "Bad Gateway", http.StatusBadGateway)
@@ -349,11 +371,18 @@ func (p *Proxy) forwardRequestWithClient(w http.ResponseWriter, r *http.Request,
 		}
 	}
 	w.WriteHeader(resp.StatusCode)
-	_, _ = io.Copy(w, resp.Body)
+
+	if isSSEResponse(resp) {
+		streamSSE(r.Context(), w, resp.Body, start)
+		return
+	}
+
+	n, _ := io.Copy(w, resp.Body)
+	metrics.addBytesOut(n)
 }
 
 func (p *Proxy) executeRequest(original *http.Request, targetURL string, body []byte, thinkingEnabled bool, transport http.RoundTripper, tweak func(req *http.Request)) (*http.Response, error) {
-	req, err := http.NewRequest(original.Method, targetURL, bytes.NewReader(body))
+	req, err := http.NewRequestWithContext(original.Context(), original.Method, targetURL, bytes.NewReader(body))
 	if err != nil {
 		return nil, err
 	}
@@ -375,7 +404,30 @@ func (p *Proxy) executeRequest(original *http.Request, targetURL string, body []
 	}
 
 	client := &http.Client{Transport: transport}
-	return client.Do(req)
+	start := time.Now()
+	resp, err := client.Do(req)
+	metrics.recordUpstreamLatency(p.targetLabel(targetURL), time.Since(start).Seconds())
+	return resp, err
+}
+
+// targetLabel maps a forwarded request's target URL to a short label for
+// the vibeproxy_upstream_latency_seconds metric.
+func (p *Proxy) targetLabel(targetURL string) string {
+	parsed, err := url.Parse(targetURL)
+	if err != nil {
+		return "unknown"
+	}
+
+	switch parsed.Host {
+	case p.targetAddr:
+		return "backend"
+	case "ampcode.com":
+		return "amp"
+	case vercelGatewayHost:
+		return "vercel"
+	default:
+		return parsed.Host
+	}
 }
 
 func (p *Proxy) writeAmpResponse(w http.ResponseWriter, resp *http.Response) {
@@ -423,6 +475,7 @@ func (p *Proxy) writeAmpResponse(w http.ResponseWriter, resp *http.Response) {
 	w.Header().Set("Content-Length", strconv.Itoa(len(body)))
 	w.WriteHeader(resp.StatusCode)
 	_, _ = w.Write(body)
+	metrics.addBytesOut(int64(len(body)))
 }
 
 func isAmpRequest(targetURL string) bool {
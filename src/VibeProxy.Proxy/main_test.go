@@ -0,0 +1,130 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// flushCountingWriter wraps an httptest.ResponseRecorder and counts Flush
+// calls, so a test can tell incremental flushing apart from a single
+// buffered write at EOF.
+type flushCountingWriter struct {
+	*httptest.ResponseRecorder
+	flushes int
+}
+
+func (w *flushCountingWriter) Flush() {
+	w.flushes++
+	w.ResponseRecorder.Flush()
+}
+
+// TestServeHTTPFlushesSSEPerEvent guards against statusRecorder hiding the
+// backend ResponseWriter's Flush method: without it, streamSSE's type
+// assertion to http.Flusher always fails and SSE responses get buffered
+// until EOF instead of streamed event-by-event.
+func TestServeHTTPFlushesSSEPerEvent(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", sseContentType)
+		w.WriteHeader(http.StatusOK)
+		flusher := w.(http.Flusher)
+
+		fmt.Fprintf(w, "data: {\"type\":\"message_start\",\"message\":{\"model\":\"claude-3-5-haiku-20241022\",\"usage\":{\"input_tokens\":1}}}\n\n")
+		flusher.Flush()
+		fmt.Fprintf(w, "data: {\"type\":\"message_stop\"}\n\n")
+		flusher.Flush()
+	}))
+	defer backend.Close()
+
+	proxy := &Proxy{
+		listenAddr: "unused",
+		targetAddr: strings.TrimPrefix(backend.URL, "http://"),
+		config:     &ConfigProvider{},
+	}
+
+	body := strings.NewReader(`{"model":"claude-3-5-haiku-20241022","max_tokens":100}`)
+	req := httptest.NewRequest(http.MethodPost, "/v1/messages", body)
+	req.Header.Set("Content-Type", "application/json")
+
+	w := &flushCountingWriter{ResponseRecorder: httptest.NewRecorder()}
+	proxy.ServeHTTP(w, req)
+
+	if w.flushes < 2 {
+		t.Fatalf("expected at least 2 flushes (one per SSE event), got %d", w.flushes)
+	}
+}
+
+// TestStatusRecorderFlushDelegates is a narrower unit test for the Flusher
+// hookup itself: wrapping a flushable ResponseWriter in a statusRecorder
+// must still type-assert to http.Flusher and forward the call.
+func TestStatusRecorderFlushDelegates(t *testing.T) {
+	backend := httptest.NewRecorder()
+	rec := &statusRecorder{ResponseWriter: backend, status: http.StatusOK}
+
+	flusher, ok := (http.ResponseWriter(rec)).(http.Flusher)
+	if !ok {
+		t.Fatal("statusRecorder does not implement http.Flusher")
+	}
+
+	flusher.Flush()
+	if !backend.Flushed {
+		t.Fatal("Flush() did not reach the embedded ResponseWriter")
+	}
+}
+
+func TestStripThinkingBudgetSuffix(t *testing.T) {
+	tests := []struct {
+		name  string
+		model string
+		want  string
+	}{
+		{"no thinking suffix is unchanged", "claude-3-5-haiku-20241022", "claude-3-5-haiku-20241022"},
+		{"claude thinking-budget suffix is stripped", "claude-3-5-haiku-20241022-thinking-5000", "claude-3-5-haiku-20241022"},
+		{"gemini-claude thinking-budget suffix keeps the -thinking marker", "gemini-claude-3-5-haiku-20241022-thinking-5000", "gemini-claude-3-5-haiku-20241022-thinking"},
+		{"bare -thinking suffix (no budget) is unchanged", "claude-opus-4-thinking", "claude-opus-4-thinking"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := stripThinkingBudgetSuffix(tt.model); got != tt.want {
+				t.Errorf("stripThinkingBudgetSuffix(%q) = %q, want %q", tt.model, got, tt.want)
+			}
+		})
+	}
+}
+
+// TestServeHTTPAllowsThinkingSuffixAgainstExactAllowedModel guards against
+// authorize() checking an exact AllowedModel against the raw, unstripped
+// model name: a token configured for exactly "claude-3-5-haiku-20241022"
+// must still be able to use that model via the documented
+// "-thinking-<budget>" suffix convention, not just the bare model name.
+func TestServeHTTPAllowsThinkingSuffixAgainstExactAllowedModel(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer backend.Close()
+
+	proxy := &Proxy{
+		listenAddr: "unused",
+		targetAddr: strings.TrimPrefix(backend.URL, "http://"),
+		config:     &ConfigProvider{},
+	}
+	proxy.config.cfg.Store(&ProxyConfig{
+		AuthTokens: []AuthToken{
+			{Name: "haiku-only", Token: "tok-haiku", AllowedModel: "claude-3-5-haiku-20241022"},
+		},
+	})
+
+	body := strings.NewReader(`{"model":"claude-3-5-haiku-20241022-thinking-5000","max_tokens":8000}`)
+	req := httptest.NewRequest(http.MethodPost, "/v1/messages", body)
+	req.Header.Set("Authorization", "Bearer tok-haiku")
+
+	w := httptest.NewRecorder()
+	proxy.ServeHTTP(w, req)
+
+	if w.Code == http.StatusUnauthorized {
+		t.Fatalf("request using the documented thinking-suffix convention was rejected (status %d): %s", w.Code, w.Body.String())
+	}
+}
@@ -0,0 +1,327 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// metricsLatencyBuckets follows the same bucket pattern Traefik uses for its
+// Prometheus middleware.
+var metricsLatencyBuckets = []float64{0.1, 0.3, 1.2, 5}
+
+// Metrics holds the proxy's in-process Prometheus-style counters and
+// histograms, served from the admin listener's /metrics endpoint.
+type Metrics struct {
+	requestsTotal     *labelCounter
+	upstreamLatency   *histogram
+	thinkingOriginal  *labelCounter
+	thinkingCleaned   *labelCounter
+	routingDecisions  *labelCounter
+	tokensInput       *labelCounter
+	tokensOutput      *labelCounter
+	tokensThinking    *labelCounter
+	budgetAdjustments uint64
+	retries404        uint64
+	requestBytesIn    uint64
+	requestBytesOut   uint64
+	inFlightRequests  int64
+}
+
+func newMetrics() *Metrics {
+	return &Metrics{
+		requestsTotal:    newLabelCounter(),
+		upstreamLatency:  newHistogram(),
+		thinkingOriginal: newLabelCounter(),
+		thinkingCleaned:  newLabelCounter(),
+		routingDecisions: newLabelCounter(),
+		tokensInput:      newLabelCounter(),
+		tokensOutput:     newLabelCounter(),
+		tokensThinking:   newLabelCounter(),
+	}
+}
+
+// metrics is the process-wide metrics registry. A single proxy process
+// serves one admin listener, so a package-level singleton avoids threading
+// a registry handle through every call site.
+var metrics = newMetrics()
+
+func (m *Metrics) recordRequest(method, path string, status int) {
+	m.requestsTotal.inc(fmt.Sprintf("method=%q,path=%q,status=%q", method, path, statusLabel(status)))
+}
+
+func (m *Metrics) recordUpstreamLatency(target string, seconds float64) {
+	m.upstreamLatency.observe(fmt.Sprintf("target=%q", target), seconds)
+}
+
+func (m *Metrics) recordThinkingTransform(original, cleaned string) {
+	m.thinkingOriginal.inc(fmt.Sprintf("model=%q", original))
+	m.thinkingCleaned.inc(fmt.Sprintf("model=%q", cleaned))
+}
+
+func (m *Metrics) recordRoutingDecision(destination string) {
+	m.routingDecisions.inc(fmt.Sprintf("destination=%q", destination))
+}
+
+// recordStreamTokens folds the input/output/thinking token counts parsed
+// from one SSE response into the per-model token counters.
+func (m *Metrics) recordStreamTokens(model string, input, output, thinking int) {
+	label := fmt.Sprintf("model=%q", model)
+	if input > 0 {
+		m.tokensInput.add(label, uint64(input))
+	}
+	if output > 0 {
+		m.tokensOutput.add(label, uint64(output))
+	}
+	if thinking > 0 {
+		m.tokensThinking.add(label, uint64(thinking))
+	}
+}
+
+func (m *Metrics) recordBudgetAdjustment() {
+	atomic.AddUint64(&m.budgetAdjustments, 1)
+}
+
+func (m *Metrics) recordRetry404() {
+	atomic.AddUint64(&m.retries404, 1)
+}
+
+func (m *Metrics) addBytesIn(n int) {
+	atomic.AddUint64(&m.requestBytesIn, uint64(n))
+}
+
+func (m *Metrics) addBytesOut(n int64) {
+	if n <= 0 {
+		return
+	}
+	atomic.AddUint64(&m.requestBytesOut, uint64(n))
+}
+
+func (m *Metrics) incInFlight() {
+	atomic.AddInt64(&m.inFlightRequests, 1)
+}
+
+func (m *Metrics) decInFlight() {
+	atomic.AddInt64(&m.inFlightRequests, -1)
+}
+
+func statusLabel(status int) string {
+	if status == 0 {
+		return "0"
+	}
+	return fmt.Sprintf("%d", status)
+}
+
+// ServeHTTP renders every registered metric in Prometheus text exposition
+// format.
+func (m *Metrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+
+	fmt.Fprintln(w, "# HELP vibeproxy_requests_total Total requests by method, path, and status.")
+	fmt.Fprintln(w, "# TYPE vibeproxy_requests_total counter")
+	m.requestsTotal.writeTo(w, "vibeproxy_requests_total")
+
+	fmt.Fprintln(w, "# HELP vibeproxy_upstream_latency_seconds Upstream request latency by target.")
+	fmt.Fprintln(w, "# TYPE vibeproxy_upstream_latency_seconds histogram")
+	m.upstreamLatency.writeTo(w, "vibeproxy_upstream_latency_seconds")
+
+	fmt.Fprintln(w, "# HELP vibeproxy_thinking_original_total Thinking-suffixed model requests by original model.")
+	fmt.Fprintln(w, "# TYPE vibeproxy_thinking_original_total counter")
+	m.thinkingOriginal.writeTo(w, "vibeproxy_thinking_original_total")
+
+	fmt.Fprintln(w, "# HELP vibeproxy_thinking_cleaned_total Thinking-suffixed model requests by cleaned model.")
+	fmt.Fprintln(w, "# TYPE vibeproxy_thinking_cleaned_total counter")
+	m.thinkingCleaned.writeTo(w, "vibeproxy_thinking_cleaned_total")
+
+	fmt.Fprintln(w, "# HELP vibeproxy_routing_decisions_total Routing decisions by destination.")
+	fmt.Fprintln(w, "# TYPE vibeproxy_routing_decisions_total counter")
+	m.routingDecisions.writeTo(w, "vibeproxy_routing_decisions_total")
+
+	fmt.Fprintln(w, "# HELP vibeproxy_tokens_input_total Input tokens observed in streamed responses, by model.")
+	fmt.Fprintln(w, "# TYPE vibeproxy_tokens_input_total counter")
+	m.tokensInput.writeTo(w, "vibeproxy_tokens_input_total")
+
+	fmt.Fprintln(w, "# HELP vibeproxy_tokens_output_total Output tokens observed in streamed responses, by model.")
+	fmt.Fprintln(w, "# TYPE vibeproxy_tokens_output_total counter")
+	m.tokensOutput.writeTo(w, "vibeproxy_tokens_output_total")
+
+	fmt.Fprintln(w, "# HELP vibeproxy_tokens_thinking_total Thinking tokens observed in streamed responses, by model.")
+	fmt.Fprintln(w, "# TYPE vibeproxy_tokens_thinking_total counter")
+	m.tokensThinking.writeTo(w, "vibeproxy_tokens_thinking_total")
+
+	fmt.Fprintln(w, "# HELP vibeproxy_budget_adjustments_total Requests whose thinking budget was clamped to hardTokenCap-1.")
+	fmt.Fprintln(w, "# TYPE vibeproxy_budget_adjustments_total counter")
+	fmt.Fprintf(w, "vibeproxy_budget_adjustments_total %d\n", atomic.LoadUint64(&m.budgetAdjustments))
+
+	fmt.Fprintln(w, "# HELP vibeproxy_retries_404_total Requests retried with an /api prefix after an upstream 404.")
+	fmt.Fprintln(w, "# TYPE vibeproxy_retries_404_total counter")
+	fmt.Fprintf(w, "vibeproxy_retries_404_total %d\n", atomic.LoadUint64(&m.retries404))
+
+	fmt.Fprintln(w, "# HELP vibeproxy_request_bytes_in_total Total request bytes read from clients.")
+	fmt.Fprintln(w, "# TYPE vibeproxy_request_bytes_in_total counter")
+	fmt.Fprintf(w, "vibeproxy_request_bytes_in_total %d\n", atomic.LoadUint64(&m.requestBytesIn))
+
+	fmt.Fprintln(w, "# HELP vibeproxy_response_bytes_out_total Total response bytes written to clients.")
+	fmt.Fprintln(w, "# TYPE vibeproxy_response_bytes_out_total counter")
+	fmt.Fprintf(w, "vibeproxy_response_bytes_out_total %d\n", atomic.LoadUint64(&m.requestBytesOut))
+
+	fmt.Fprintln(w, "# HELP vibeproxy_in_flight_requests Requests currently being handled.")
+	fmt.Fprintln(w, "# TYPE vibeproxy_in_flight_requests gauge")
+	fmt.Fprintf(w, "vibeproxy_in_flight_requests %d\n", atomic.LoadInt64(&m.inFlightRequests))
+}
+
+// startAdminServer starts the admin listener (metrics and /admin/tokens) if
+// addr is non-empty. It runs in its own goroutine and logs a fatal error if
+// the listener fails to start.
+func startAdminServer(addr string, config *ConfigProvider) {
+	if addr == "" {
+		return
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", metrics)
+	mux.HandleFunc("/admin/tokens", tokensHandler(config))
+	mux.HandleFunc("/admin/config/status", configStatusHandler(config))
+
+	srv := &http.Server{
+		Addr:              addr,
+		Handler:           mux,
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+
+	go func() {
+		log.Printf("[ThinkingProxy] Admin listener on %s", addr)
+		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			log.Fatalf("admin ListenAndServe failed: %v", err)
+		}
+	}()
+}
+
+// statusRecorder wraps an http.ResponseWriter to capture the status code
+// written, for the vibeproxy_requests_total metric.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (rec *statusRecorder) WriteHeader(status int) {
+	rec.status = status
+	rec.ResponseWriter.WriteHeader(status)
+}
+
+// Flush implements http.Flusher by delegating to the embedded
+// ResponseWriter, if it supports flushing. Without this, wrapping a
+// flushable ResponseWriter in a statusRecorder would silently hide that
+// capability: embedding the http.ResponseWriter interface does not promote
+// a Flush method the way embedding a concrete *http.response would, so a
+// type assertion on the wrapper always fails and streamSSE would buffer
+// instead of flushing per event.
+func (rec *statusRecorder) Flush() {
+	if flusher, ok := rec.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// labelCounter is a counter vector keyed by a pre-formatted label string.
+type labelCounter struct {
+	mu     sync.Mutex
+	values map[string]uint64
+}
+
+func newLabelCounter() *labelCounter {
+	return &labelCounter{values: make(map[string]uint64)}
+}
+
+func (c *labelCounter) inc(labels string) {
+	c.add(labels, 1)
+}
+
+func (c *labelCounter) add(labels string, delta uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.values[labels] += delta
+}
+
+func (c *labelCounter) writeTo(w http.ResponseWriter, name string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	keys := make([]string, 0, len(c.values))
+	for k := range c.values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, labels := range keys {
+		fmt.Fprintf(w, "%s{%s} %d\n", name, labels, c.values[labels])
+	}
+}
+
+// histogram is a Prometheus-style cumulative histogram keyed by a
+// pre-formatted label string, using the fixed metricsLatencyBuckets.
+type histogram struct {
+	mu      sync.Mutex
+	buckets map[string][]uint64
+	sums    map[string]float64
+	counts  map[string]uint64
+}
+
+func newHistogram() *histogram {
+	return &histogram{
+		buckets: make(map[string][]uint64),
+		sums:    make(map[string]float64),
+		counts:  make(map[string]uint64),
+	}
+}
+
+func (h *histogram) observe(labels string, seconds float64) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	counts, ok := h.buckets[labels]
+	if !ok {
+		counts = make([]uint64, len(metricsLatencyBuckets))
+		h.buckets[labels] = counts
+	}
+	for i, le := range metricsLatencyBuckets {
+		if seconds <= le {
+			counts[i]++
+		}
+	}
+	h.sums[labels] += seconds
+	h.counts[labels]++
+}
+
+func (h *histogram) writeTo(w http.ResponseWriter, name string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	keys := make([]string, 0, len(h.buckets))
+	for k := range h.buckets {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, labels := range keys {
+		counts := h.buckets[labels]
+		prefix := labels
+		if prefix != "" {
+			prefix += ","
+		}
+		for i, le := range metricsLatencyBuckets {
+			fmt.Fprintf(w, "%s_bucket{%sle=%q} %d\n", name, prefix, formatBucket(le), counts[i])
+		}
+		fmt.Fprintf(w, "%s_bucket{%sle=\"+Inf\"} %d\n", name, prefix, h.counts[labels])
+		fmt.Fprintf(w, "%s_sum{%s} %g\n", name, labels, h.sums[labels])
+		fmt.Fprintf(w, "%s_count{%s} %d\n", name, labels, h.counts[labels])
+	}
+}
+
+func formatBucket(le float64) string {
+	return strings.TrimRight(strings.TrimRight(fmt.Sprintf("%f", le), "0"), ".")
+}
@@ -0,0 +1,259 @@
+package main
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+	"time"
+)
+
+// Provider identifies the kind of upstream a Route forwards to.
+type Provider string
+
+const (
+	ProviderLocal     Provider = "local"
+	ProviderAmp       Provider = "amp"
+	ProviderVercel    Provider = "vercel"
+	ProviderAnthropic Provider = "anthropic"
+	ProviderOpenAI    Provider = "openai"
+	ProviderBedrock   Provider = "bedrock"
+)
+
+// knownProviders is the set of Provider values a Route may legally name;
+// validateConfig rejects anything else instead of loading a route that
+// would silently forward unauthenticated requests at runtime.
+var knownProviders = map[Provider]bool{
+	ProviderLocal:     true,
+	ProviderAmp:       true,
+	ProviderVercel:    true,
+	ProviderAnthropic: true,
+	ProviderOpenAI:    true,
+	ProviderBedrock:   true,
+}
+
+const (
+	defaultDialTimeoutSeconds  = 10
+	defaultReadTimeoutSeconds  = 60
+	defaultWriteTimeoutSeconds = 60
+)
+
+// Route is one entry of the model/path-to-provider routing table. A request
+// whose model matches Model (prefix or glob, e.g. "claude-*") or whose path
+// matches Path is forwarded to Provider at BaseURL, using its own credential
+// and timeout settings rather than the proxy-wide defaults.
+type Route struct {
+	Name     string   `json:"name"`
+	Model    string   `json:"model"`
+	Path     string   `json:"path"`
+	Provider Provider `json:"provider"`
+	BaseURL  string   `json:"baseUrl"`
+
+	CredentialEnv  string `json:"credentialEnv"`
+	CredentialFile string `json:"credentialFile"`
+
+	// Region is the AWS region to sign requests for. Only meaningful (and
+	// required) when Provider is ProviderBedrock.
+	Region string `json:"region"`
+
+	ServerName string `json:"serverName"`
+	Insecure   bool   `json:"insecure"`
+
+	DialTimeoutSeconds  int `json:"dialTimeoutSeconds"`
+	ReadTimeoutSeconds  int `json:"readTimeoutSeconds"`
+	WriteTimeoutSeconds int `json:"writeTimeoutSeconds"`
+}
+
+// Matches reports whether the route applies to a request for the given
+// model name and request path. An empty Model or Path is treated as "any".
+func (route Route) Matches(model, requestPath string) bool {
+	if route.Model != "" && !matchesModelPattern(route.Model, model) {
+		return false
+	}
+	if route.Path != "" && !matchesModelPattern(route.Path, requestPath) {
+		return false
+	}
+	return route.Model != "" || route.Path != ""
+}
+
+// matchesModelPattern reports whether value matches pattern, shared by
+// Route.Matches and AuthToken.allowsModel. A pattern ending in "*" is a
+// prefix glob (path.Match, falling back to a plain prefix check for
+// patterns path.Match would otherwise reject, e.g. one containing a
+// literal "["). A pattern with no trailing "*" must match value exactly -
+// unlike path.Match failing silently into a prefix match, an allow-list
+// entry like "claude-3-5-haiku-20241022" must not also grant
+// "claude-3-5-haiku-20241022-thinking-999999".
+func matchesModelPattern(pattern, value string) bool {
+	if strings.HasSuffix(pattern, "*") {
+		if ok, err := path.Match(pattern, value); err == nil && ok {
+			return true
+		}
+		return strings.HasPrefix(value, strings.TrimSuffix(pattern, "*"))
+	}
+	ok, err := path.Match(pattern, value)
+	return err == nil && ok
+}
+
+// Credential resolves the route's credential from its env var or file,
+// env var taking precedence when both are set.
+func (route Route) Credential() string {
+	if route.CredentialEnv != "" {
+		if value := os.Getenv(route.CredentialEnv); value != "" {
+			return value
+		}
+	}
+	if route.CredentialFile != "" {
+		data, err := os.ReadFile(route.CredentialFile)
+		if err == nil {
+			return strings.TrimSpace(string(data))
+		}
+	}
+	return ""
+}
+
+// bedrockCredentials splits Credential() into the AWS access key ID and
+// secret access key a ProviderBedrock route needs to sign requests,
+// expected in "<accessKeyID>:<secretAccessKey>" form.
+func (route Route) bedrockCredentials() (accessKeyID, secretAccessKey string, ok bool) {
+	accessKeyID, secretAccessKey, ok = strings.Cut(route.Credential(), ":")
+	return accessKeyID, secretAccessKey, ok && accessKeyID != "" && secretAccessKey != ""
+}
+
+func (route Route) dialTimeout() time.Duration {
+	if route.DialTimeoutSeconds > 0 {
+		return time.Duration(route.DialTimeoutSeconds) * time.Second
+	}
+	return defaultDialTimeoutSeconds * time.Second
+}
+
+func (route Route) readTimeout() time.Duration {
+	if route.ReadTimeoutSeconds > 0 {
+		return time.Duration(route.ReadTimeoutSeconds) * time.Second
+	}
+	return defaultReadTimeoutSeconds * time.Second
+}
+
+func (route Route) writeTimeout() time.Duration {
+	if route.WriteTimeoutSeconds > 0 {
+		return time.Duration(route.WriteTimeoutSeconds) * time.Second
+	}
+	return defaultWriteTimeoutSeconds * time.Second
+}
+
+// transport builds an *http.Transport honoring the route's TLS ServerName
+// override, insecure flag, and dial/read timeouts, mirroring the
+// https+insecure:// handling in expandProxyArg.
+func (route Route) transport() *http.Transport {
+	tlsConfig := &tls.Config{InsecureSkipVerify: route.Insecure}
+	if route.ServerName != "" {
+		tlsConfig.ServerName = route.ServerName
+	}
+
+	dialTimeout := route.dialTimeout()
+	return &http.Transport{
+		TLSClientConfig: tlsConfig,
+		DialContext: (&net.Dialer{
+			Timeout: dialTimeout,
+		}).DialContext,
+		ResponseHeaderTimeout: route.readTimeout(),
+	}
+}
+
+// defaultAccountRoute reproduces the proxy's historical hardcoded Amp
+// account-proxying behavior (forwardToAmp's ampcode.com target) as a
+// catch-all route entry for paths outside the Claude messages API.
+func defaultAccountRoute() Route {
+	return Route{
+		Name:       "amp-account",
+		Path:       "*",
+		Provider:   ProviderAmp,
+		BaseURL:    "https://ampcode.com",
+		ServerName: "ampcode.com",
+	}
+}
+
+// defaultModelRoutes reproduces the proxy's historical hardcoded
+// forwardToVercel behavior: Claude-family models go to Vercel AI Gateway
+// when it's configured and enabled.
+func defaultModelRoutes(cfg ProxyConfig) []Route {
+	if !cfg.VercelEnabled {
+		return nil
+	}
+
+	vercelURL := fmt.Sprintf("https://%s", vercelGatewayHost)
+	return []Route{
+		{
+			Name:       "vercel-claude",
+			Model:      "claude-*",
+			Provider:   ProviderVercel,
+			BaseURL:    vercelURL,
+			ServerName: vercelGatewayHost,
+		},
+		{
+			Name:       "vercel-gemini-claude",
+			Model:      "gemini-claude-*",
+			Provider:   ProviderVercel,
+			BaseURL:    vercelURL,
+			ServerName: vercelGatewayHost,
+		},
+	}
+}
+
+// resolveRoute returns the first configured or default route matching the
+// request's model and path, in that order. Configured routes always take
+// precedence over the built-in defaults so operators can override them.
+// defaults is the set of fallback routes appropriate to the call site
+// (account-proxying vs. model-messages), since they apply to disjoint path
+// spaces and must not shadow one another.
+func resolveRoute(cfg ProxyConfig, model, requestPath string, defaults []Route) (Route, bool) {
+	for _, route := range cfg.Routes {
+		if route.Matches(model, requestPath) {
+			return route, true
+		}
+	}
+	for _, route := range defaults {
+		if route.Matches(model, requestPath) {
+			return route, true
+		}
+	}
+	return Route{}, false
+}
+
+// forwardToProvider is the generic replacement for the old
+// forwardToVercel/forwardToAmp special cases: it builds a client from the
+// route's transport and credential, applies provider-specific auth headers,
+// and forwards the request to route.BaseURL+path.
+func (p *Proxy) forwardToProvider(route Route, w http.ResponseWriter, r *http.Request, requestPath string, body []byte, thinkingEnabled bool) {
+	targetURL := strings.TrimSuffix(route.BaseURL, "/") + requestPath
+	transport := route.transport()
+	credential := route.Credential()
+
+	tweak := func(req *http.Request) {
+		switch route.Provider {
+		case ProviderVercel, ProviderAnthropic:
+			if credential != "" {
+				req.Header.Set("x-api-key", credential)
+			}
+			req.Header.Set("anthropic-version", anthropicVersion)
+			req.Header.Set("content-type", "application/json")
+		case ProviderOpenAI:
+			if credential != "" {
+				req.Header.Set("Authorization", "Bearer "+credential)
+			}
+			req.Header.Set("content-type", "application/json")
+		case ProviderBedrock:
+			req.Header.Set("content-type", "application/json")
+			if accessKeyID, secretAccessKey, ok := route.bedrockCredentials(); ok {
+				signSigV4(req, body, "bedrock", route.Region, accessKeyID, secretAccessKey, "")
+			}
+		case ProviderAmp:
+			req.Host = route.ServerName
+		}
+	}
+
+	p.forwardRequestWithClient(w, r, targetURL, body, thinkingEnabled, transport, tweak)
+}
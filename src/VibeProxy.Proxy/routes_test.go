@@ -0,0 +1,74 @@
+package main
+
+import "testing"
+
+func TestMatchesModelPattern(t *testing.T) {
+	tests := []struct {
+		name    string
+		pattern string
+		value   string
+		want    bool
+	}{
+		{"trailing glob matches prefix", "claude-*", "claude-opus-4", true},
+		{"trailing glob rejects non-prefix", "claude-*", "gpt-4o", false},
+		{"exact pattern matches identical value", "claude-3-5-haiku-20241022", "claude-3-5-haiku-20241022", true},
+		{"exact pattern rejects a longer value with the same prefix", "claude-3-5-haiku-20241022", "claude-3-5-haiku-20241022-thinking-999999", false},
+		{"exact pattern rejects an unrelated value", "claude-3-5-haiku-20241022", "gpt-4o", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := matchesModelPattern(tt.pattern, tt.value); got != tt.want {
+				t.Errorf("matchesModelPattern(%q, %q) = %v, want %v", tt.pattern, tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRouteMatches(t *testing.T) {
+	route := Route{Model: "claude-*"}
+	if !route.Matches("claude-opus-4", "/v1/messages") {
+		t.Fatal("expected route to match a model-only pattern regardless of path")
+	}
+	if route.Matches("gpt-4o", "/v1/messages") {
+		t.Fatal("expected route not to match a model outside its pattern")
+	}
+
+	empty := Route{}
+	if empty.Matches("claude-opus-4", "/v1/messages") {
+		t.Fatal("a route with no Model or Path should never match")
+	}
+}
+
+func TestRouteBedrockCredentials(t *testing.T) {
+	tests := []struct {
+		name          string
+		credentialEnv string
+		envValue      string
+		wantAccessKey string
+		wantSecretKey string
+		wantOK        bool
+	}{
+		{"well-formed access:secret pair", "BEDROCK_CREDS", "AKIDEXAMPLE:wJalrXUtnFEMI/K7MDENG/bPxRfiCYEXAMPLEKEY", "AKIDEXAMPLE", "wJalrXUtnFEMI/K7MDENG/bPxRfiCYEXAMPLEKEY", true},
+		{"missing secret half", "BEDROCK_CREDS", "AKIDEXAMPLE", "", "", false},
+		{"no credential configured", "", "", "", "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.credentialEnv != "" {
+				t.Setenv(tt.credentialEnv, tt.envValue)
+			}
+			route := Route{CredentialEnv: tt.credentialEnv}
+
+			accessKeyID, secretAccessKey, ok := route.bedrockCredentials()
+			if ok != tt.wantOK {
+				t.Fatalf("bedrockCredentials() ok = %v, want %v", ok, tt.wantOK)
+			}
+			if ok && (accessKeyID != tt.wantAccessKey || secretAccessKey != tt.wantSecretKey) {
+				t.Fatalf("bedrockCredentials() = (%q, %q), want (%q, %q)",
+					accessKeyID, secretAccessKey, tt.wantAccessKey, tt.wantSecretKey)
+			}
+		})
+	}
+}
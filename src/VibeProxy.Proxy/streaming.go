@@ -0,0 +1,171 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const sseContentType = "text/event-stream"
+
+type thinkingBudgetContextKey struct{}
+type authTokenKeyContextKey struct{}
+
+// withThinkingBudget attaches the request's requested thinking budget to its
+// context so downstream forwarding code can report it without threading an
+// extra parameter through every forwardTo*/forwardRequestWith* call.
+func withThinkingBudget(r *http.Request, budget int) *http.Request {
+	if budget <= 0 {
+		return r
+	}
+	return r.WithContext(context.WithValue(r.Context(), thinkingBudgetContextKey{}, budget))
+}
+
+func thinkingBudgetFrom(ctx context.Context) int {
+	budget, _ := ctx.Value(thinkingBudgetContextKey{}).(int)
+	return budget
+}
+
+// withAuthTokenKey attaches the matched AuthToken's key to the request
+// context so the SSE parser can meter its thinking-token consumption
+// without threading the token through every forwarding call.
+func withAuthTokenKey(r *http.Request, key string) *http.Request {
+	if key == "" {
+		return r
+	}
+	return r.WithContext(context.WithValue(r.Context(), authTokenKeyContextKey{}, key))
+}
+
+func authTokenKeyFrom(ctx context.Context) string {
+	key, _ := ctx.Value(authTokenKeyContextKey{}).(string)
+	return key
+}
+
+// isSSEResponse reports whether an upstream response is an Anthropic
+// text/event-stream that should be parsed and flushed incrementally rather
+// than bulk-copied.
+func isSSEResponse(resp *http.Response) bool {
+	return strings.Contains(resp.Header.Get("Content-Type"), sseContentType)
+}
+
+// sseCounters accumulates token usage observed while parsing an Anthropic
+// streaming response, for the message_stop summary log line and the
+// Prometheus token counters.
+type sseCounters struct {
+	model          string
+	thinkingBudget int
+	inputTokens    int
+	outputTokens   int
+	thinkingTokens int
+}
+
+// streamSSE copies an Anthropic text/event-stream response to w one event at
+// a time, flushing after every "\n\n" boundary so the client sees tokens as
+// they arrive, while parsing message_start/content_block_delta/
+// message_delta/message_stop events to accumulate live token counters. It
+// logs a summary line and feeds the counters into Prometheus at
+// message_stop.
+func streamSSE(ctx context.Context, w http.ResponseWriter, body io.Reader, start time.Time) {
+	flusher, _ := w.(http.Flusher)
+	counters := &sseCounters{thinkingBudget: thinkingBudgetFrom(ctx)}
+
+	reader := bufio.NewReader(body)
+	var event strings.Builder
+
+	flushEvent := func() {
+		if event.Len() == 0 {
+			return
+		}
+		chunk := event.String()
+		_, _ = io.WriteString(w, chunk)
+		if flusher != nil {
+			flusher.Flush()
+		}
+		parseSSEEvent(chunk, counters)
+		event.Reset()
+	}
+
+	for {
+		line, err := reader.ReadString('\n')
+		event.WriteString(line)
+
+		if strings.TrimRight(line, "\r\n") == "" {
+			flushEvent()
+		}
+
+		if err != nil {
+			flushEvent()
+			break
+		}
+	}
+
+	log.Printf("[ThinkingProxy] SSE summary model=%s thinking_budget_requested=%d thinking_tokens_consumed=%d input_tokens=%d output_tokens=%d latency=%s",
+		counters.model, counters.thinkingBudget, counters.thinkingTokens, counters.inputTokens, counters.outputTokens, time.Since(start))
+
+	metrics.recordStreamTokens(counters.model, counters.inputTokens, counters.outputTokens, counters.thinkingTokens)
+	authStore.consume(authTokenKeyFrom(ctx), counters.thinkingTokens)
+}
+
+// parseSSEEvent extracts the "data: " payload(s) of one SSE event and folds
+// any token-relevant fields into counters.
+func parseSSEEvent(chunk string, counters *sseCounters) {
+	for _, line := range strings.Split(chunk, "\n") {
+		line = strings.TrimRight(line, "\r")
+		data := strings.TrimPrefix(line, "data:")
+		if data == line {
+			continue
+		}
+		data = strings.TrimSpace(data)
+		if data == "" {
+			continue
+		}
+
+		var payload map[string]interface{}
+		if err := json.Unmarshal([]byte(data), &payload); err != nil {
+			continue
+		}
+
+		switch payload["type"] {
+		case "message_start":
+			message, _ := payload["message"].(map[string]interface{})
+			if model, ok := message["model"].(string); ok {
+				counters.model = model
+			}
+			if usage, ok := message["usage"].(map[string]interface{}); ok {
+				if tokens, ok := usage["input_tokens"].(float64); ok {
+					counters.inputTokens = int(tokens)
+				}
+			}
+		case "content_block_delta":
+			delta, _ := payload["delta"].(map[string]interface{})
+			switch delta["type"] {
+			case "thinking_delta":
+				if thinking, ok := delta["thinking"].(string); ok {
+					counters.thinkingTokens += estimateTokens(thinking)
+				}
+			case "text_delta":
+				if text, ok := delta["text"].(string); ok {
+					counters.outputTokens += estimateTokens(text)
+				}
+			}
+		case "message_delta":
+			if usage, ok := payload["usage"].(map[string]interface{}); ok {
+				if tokens, ok := usage["output_tokens"].(float64); ok {
+					counters.outputTokens = int(tokens)
+				}
+			}
+		}
+	}
+}
+
+// estimateTokens gives a rough live token count for a streamed delta, good
+// enough for in-flight accounting; message_delta's authoritative usage
+// overwrites it once the turn completes.
+func estimateTokens(text string) int {
+	return len(strings.Fields(text))
+}
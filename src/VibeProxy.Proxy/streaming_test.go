@@ -0,0 +1,85 @@
+package main
+
+import "testing"
+
+func TestParseSSEEvent(t *testing.T) {
+	tests := []struct {
+		name  string
+		chunk string
+		want  sseCounters
+	}{
+		{
+			name:  "message_start captures model and input tokens",
+			chunk: "data: {\"type\":\"message_start\",\"message\":{\"model\":\"claude-3-5-haiku-20241022\",\"usage\":{\"input_tokens\":42}}}\n\n",
+			want:  sseCounters{model: "claude-3-5-haiku-20241022", inputTokens: 42},
+		},
+		{
+			name:  "thinking_delta accumulates estimated thinking tokens",
+			chunk: "data: {\"type\":\"content_block_delta\",\"delta\":{\"type\":\"thinking_delta\",\"thinking\":\"one two three\"}}\n\n",
+			want:  sseCounters{thinkingTokens: 3},
+		},
+		{
+			name:  "text_delta accumulates estimated output tokens",
+			chunk: "data: {\"type\":\"content_block_delta\",\"delta\":{\"type\":\"text_delta\",\"text\":\"one two\"}}\n\n",
+			want:  sseCounters{outputTokens: 2},
+		},
+		{
+			name:  "message_delta overwrites output tokens with the authoritative usage count",
+			chunk: "data: {\"type\":\"message_delta\",\"usage\":{\"output_tokens\":99}}\n\n",
+			want:  sseCounters{outputTokens: 99},
+		},
+		{
+			name:  "non-data lines are ignored",
+			chunk: "event: ping\n\n",
+			want:  sseCounters{},
+		},
+		{
+			name:  "unparseable payload is ignored",
+			chunk: "data: not json\n\n",
+			want:  sseCounters{},
+		},
+		{
+			name:  "unknown event type is ignored",
+			chunk: "data: {\"type\":\"message_stop\"}\n\n",
+			want:  sseCounters{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := &sseCounters{}
+			parseSSEEvent(tt.chunk, got)
+			if *got != tt.want {
+				t.Errorf("parseSSEEvent(%q) = %+v, want %+v", tt.chunk, *got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseSSEEventAccumulatesAcrossCalls(t *testing.T) {
+	counters := &sseCounters{}
+	parseSSEEvent("data: {\"type\":\"content_block_delta\",\"delta\":{\"type\":\"thinking_delta\",\"thinking\":\"a b\"}}\n\n", counters)
+	parseSSEEvent("data: {\"type\":\"content_block_delta\",\"delta\":{\"type\":\"thinking_delta\",\"thinking\":\"c\"}}\n\n", counters)
+
+	if counters.thinkingTokens != 3 {
+		t.Fatalf("thinkingTokens = %d, want 3", counters.thinkingTokens)
+	}
+}
+
+func TestEstimateTokens(t *testing.T) {
+	tests := []struct {
+		text string
+		want int
+	}{
+		{"", 0},
+		{"hello", 1},
+		{"hello world", 2},
+		{"  leading  and trailing  spaces  ", 4},
+	}
+
+	for _, tt := range tests {
+		if got := estimateTokens(tt.text); got != tt.want {
+			t.Errorf("estimateTokens(%q) = %d, want %d", tt.text, got, tt.want)
+		}
+	}
+}